@@ -0,0 +1,164 @@
+package chcluster
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.uber.org/zap"
+
+	"github.com/go-faster/ch"
+)
+
+// Options configures a Cluster.
+type Options struct {
+	// Nodes are the initial cluster members.
+	Nodes []*Node
+	// VirtualNodes is the number of ring points per real Node, see
+	// DefaultVirtualNodes.
+	VirtualNodes int
+	// HealthCheckInterval is how often nodes are pinged. If zero, health
+	// checking is disabled.
+	HealthCheckInterval time.Duration
+	// Logger is used to report node health transitions.
+	Logger *zap.Logger
+}
+
+// Cluster routes queries to a pool of ch.Client connections using a
+// consistent hashing Ring, so that repeated queries for the same shard
+// key land on the same node, and node churn only remaps a small fraction
+// of keys.
+//
+// Use Insert/Query with a sharding key to pick a node deterministically,
+// or Next for round-robin when no key is available.
+type Cluster struct {
+	lg     *zap.Logger
+	ring   *Ring
+	nodes  []*Node // every configured node, regardless of current health
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	rr uint64 // round-robin cursor, see Next
+}
+
+// New returns a Cluster managing the given nodes.
+func New(opt Options) *Cluster {
+	if opt.Logger == nil {
+		opt.Logger = zap.NewNop()
+	}
+
+	ring := NewRing(opt.VirtualNodes)
+	for _, n := range opt.Nodes {
+		ring.Add(n)
+	}
+
+	c := &Cluster{
+		lg:    opt.Logger,
+		ring:  ring,
+		nodes: opt.Nodes,
+		done:  make(chan struct{}),
+	}
+
+	if opt.HealthCheckInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cancel = cancel
+		go c.healthCheckLoop(ctx, opt.HealthCheckInterval)
+	} else {
+		close(c.done)
+	}
+
+	return c
+}
+
+// Close stops background health checking.
+func (c *Cluster) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	return nil
+}
+
+func (c *Cluster) healthCheckLoop(ctx context.Context, interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkHealth(ctx)
+		}
+	}
+}
+
+func (c *Cluster) checkHealth(ctx context.Context) {
+	for _, n := range c.nodes {
+		err := n.Client.Ping(ctx)
+		inRing := c.inRing(n.Name)
+		switch {
+		case err != nil && inRing:
+			c.lg.Warn("Node unhealthy, removing", zap.String("node", n.Name), zap.Error(err))
+			c.ring.Remove(n.Name)
+		case err == nil && !inRing:
+			c.lg.Info("Node recovered, re-adding", zap.String("node", n.Name))
+			c.ring.Add(n)
+		}
+	}
+}
+
+func (c *Cluster) inRing(name string) bool {
+	for _, known := range c.ring.Nodes() {
+		if known.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Pick returns the Node owning key, or an arbitrary node if key is empty.
+func (c *Cluster) Pick(key []byte) (*Node, error) {
+	if len(key) == 0 {
+		return c.Next()
+	}
+	n := c.ring.Get(key)
+	if n == nil {
+		return nil, ErrNoNodes
+	}
+	return n, nil
+}
+
+// Next returns the next node in round-robin order, ignoring sharding.
+func (c *Cluster) Next() (*Node, error) {
+	nodes := c.ring.Nodes()
+	if len(nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+	i := atomic.AddUint64(&c.rr, 1)
+	return nodes[int(i)%len(nodes)], nil
+}
+
+// Query runs q on the node owning key (or a round-robin node if key is
+// empty) and returns its Result.
+func (c *Cluster) Query(ctx context.Context, key []byte, q ch.Query) (ch.Result, error) {
+	n, err := c.Pick(key)
+	if err != nil {
+		return ch.Result{}, errors.Wrap(err, "pick node")
+	}
+	res, err := n.Client.Do(ctx, q)
+	if err != nil {
+		return ch.Result{}, errors.Wrapf(err, "query %q", n.Name)
+	}
+	return res, nil
+}
+
+// Insert is Query with intent made explicit at call sites; inserts are
+// routed the same way as any other query, by key.
+func (c *Cluster) Insert(ctx context.Context, key []byte, q ch.Query) error {
+	_, err := c.Query(ctx, key, q)
+	return err
+}
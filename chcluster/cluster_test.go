@@ -0,0 +1,73 @@
+package chcluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCluster_Next(t *testing.T) {
+	c := New(Options{
+		Nodes: []*Node{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+	})
+	defer c.Close()
+
+	seen := map[string]int{}
+	for i := 0; i < 9; i++ {
+		n, err := c.Next()
+		require.NoError(t, err)
+		seen[n.Name]++
+	}
+	require.Equal(t, map[string]int{"a": 3, "b": 3, "c": 3}, seen,
+		"round-robin must visit every node equally often across repeated calls")
+}
+
+func TestCluster_Next_NoNodes(t *testing.T) {
+	c := New(Options{})
+	defer c.Close()
+
+	_, err := c.Next()
+	require.ErrorIs(t, err, ErrNoNodes)
+}
+
+func TestCluster_Pick_EmptyKeyUsesNext(t *testing.T) {
+	c := New(Options{
+		Nodes: []*Node{{Name: "a"}, {Name: "b"}},
+	})
+	defer c.Close()
+
+	n, err := c.Pick(nil)
+	require.NoError(t, err)
+	require.NotNil(t, n)
+}
+
+// TestCluster_InRing_TracksRingMembership exercises the Add/Remove
+// transitions checkHealth relies on to decide whether a node needs to be
+// re-added or removed, without requiring a live ch.Client to Ping.
+func TestCluster_InRing_TracksRingMembership(t *testing.T) {
+	a := &Node{Name: "a"}
+	c := &Cluster{
+		lg:   zap.NewNop(),
+		ring: NewRing(DefaultVirtualNodes),
+		done: make(chan struct{}),
+	}
+	close(c.done)
+
+	require.False(t, c.inRing(a.Name))
+
+	c.ring.Add(a)
+	require.True(t, c.inRing(a.Name))
+
+	c.ring.Remove(a.Name)
+	require.False(t, c.inRing(a.Name))
+}
+
+func TestCluster_Close_StopsHealthCheck(t *testing.T) {
+	c := New(Options{
+		Nodes:               []*Node{{Name: "a"}},
+		HealthCheckInterval: time.Millisecond,
+	})
+	require.NoError(t, c.Close())
+}
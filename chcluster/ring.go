@@ -0,0 +1,139 @@
+// Package chcluster provides a consistent-hash router over a pool of
+// ch.Client connections to a ClickHouse cluster.
+package chcluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-faster/errors"
+
+	"github.com/go-faster/ch"
+)
+
+// DefaultVirtualNodes is the default number of virtual nodes placed on the
+// ring per real Node, used when Options.VirtualNodes is zero.
+const DefaultVirtualNodes = 100
+
+// Node is a single ClickHouse endpoint known to a Ring.
+type Node struct {
+	// Name uniquely identifies the node on the ring, e.g. its address.
+	Name string
+	// Client is the connection used to query this node.
+	Client *ch.Client
+}
+
+type ringItem struct {
+	hash uint32
+	node *Node
+}
+
+// Ring is a consistent hashing ring of Nodes.
+//
+// Ring is safe for concurrent use. Add and Remove rebuild the underlying
+// sorted slice, so Get only ever remaps roughly 1/N of keys when a node
+// is added or removed.
+type Ring struct {
+	mu           sync.RWMutex
+	virtualNodes int
+	items        []ringItem // sorted by hash
+	nodes        map[string]*Node
+}
+
+// NewRing returns an empty Ring. virtualNodes is the number of points
+// placed on the ring per real Node; if zero, DefaultVirtualNodes is used.
+func NewRing(virtualNodes int) *Ring {
+	if virtualNodes == 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &Ring{
+		virtualNodes: virtualNodes,
+		nodes:        map[string]*Node{},
+	}
+}
+
+func virtualKey(name string, i int) []byte {
+	return []byte(name + "#" + strconv.Itoa(i))
+}
+
+// Add inserts node into the ring, placing Ring.virtualNodes points for it.
+func (r *Ring) Add(node *Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[node.Name]; ok {
+		return
+	}
+	r.nodes[node.Name] = node
+
+	for i := 0; i < r.virtualNodes; i++ {
+		r.items = append(r.items, ringItem{
+			hash: crc32.ChecksumIEEE(virtualKey(node.Name, i)),
+			node: node,
+		})
+	}
+	sort.Slice(r.items, func(i, j int) bool {
+		return r.items[i].hash < r.items[j].hash
+	})
+}
+
+// Remove removes the node with the given name from the ring, if present.
+func (r *Ring) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[name]; !ok {
+		return
+	}
+	delete(r.nodes, name)
+
+	filtered := r.items[:0]
+	for _, it := range r.items {
+		if it.node.Name != name {
+			filtered = append(filtered, it)
+		}
+	}
+	r.items = filtered
+}
+
+// Get returns the Node owning key, or nil if the ring is empty.
+func (r *Ring) Get(key []byte) *Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.items) == 0 {
+		return nil
+	}
+
+	h := crc32.ChecksumIEEE(key)
+	idx := sort.Search(len(r.items), func(i int) bool {
+		return r.items[i].hash >= h
+	})
+	if idx == len(r.items) {
+		idx = 0
+	}
+	return r.items[idx].node
+}
+
+// Nodes returns the distinct real nodes currently on the ring, ordered by
+// Name. The order is deterministic across calls (unlike ranging over a
+// map directly), so callers such as Cluster.Next can round-robin over it.
+func (r *Ring) Nodes() []*Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*Node, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// ErrNoNodes is returned when an operation requires a node but the ring
+// is empty.
+var ErrNoNodes = errors.New("chcluster: no nodes available")
@@ -0,0 +1,63 @@
+package chcluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing_Get(t *testing.T) {
+	r := NewRing(50)
+	require.Nil(t, r.Get([]byte("k")))
+
+	a := &Node{Name: "a"}
+	b := &Node{Name: "b"}
+	r.Add(a)
+	r.Add(b)
+
+	key := []byte("some-shard-key")
+	first := r.Get(key)
+	require.NotNil(t, first)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, first, r.Get(key), "same key must always map to same node")
+	}
+}
+
+func TestRing_RemoveRemaps(t *testing.T) {
+	r := NewRing(100)
+	nodes := []*Node{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	for _, n := range nodes {
+		r.Add(n)
+	}
+
+	keys := make([][]byte, 1000)
+	before := make([]*Node, len(keys))
+	for i := range keys {
+		keys[i] = []byte{byte(i), byte(i >> 8)}
+		before[i] = r.Get(keys[i])
+	}
+
+	r.Remove("b")
+	require.Len(t, r.Nodes(), 2)
+
+	var remapped int
+	for i, k := range keys {
+		if before[i].Name == "b" {
+			continue // must have moved, b is gone
+		}
+		if r.Get(k).Name != before[i].Name {
+			remapped++
+		}
+	}
+	// Removing one of three nodes should not disturb keys owned by the
+	// other two.
+	require.Zero(t, remapped)
+}
+
+func TestRing_AddIsIdempotent(t *testing.T) {
+	r := NewRing(10)
+	n := &Node{Name: "a"}
+	r.Add(n)
+	r.Add(n)
+	require.Len(t, r.Nodes(), 1)
+}
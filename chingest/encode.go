@@ -0,0 +1,131 @@
+package chingest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/go-faster/ch/proto"
+)
+
+// encodeRows lays rows out column-oriented into a proto.Input block.
+// Each column's type is inferred from the first row that defines it;
+// a later row with a differently-typed value for the same column is an
+// error, since ClickHouse columns are fixed-type.
+//
+// insert (writer.go) sends the resulting block with "INSERT INTO table
+// FORMAT Native" and no explicit column list, so the wire order here
+// must match the table schema positionally and, crucially, must be the
+// same on every flush. Rows is a map, so the order columns are first
+// seen in is randomized per run; encodeRows sorts the final column list
+// by name rather than emitting discovery order.
+func encodeRows(rows []Row) (proto.Input, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	order := make([]string, 0, len(rows[0]))
+	cols := make(map[string]proto.Column, len(rows[0]))
+
+	for i, row := range rows {
+		seen := make(map[string]bool, len(row))
+		for name, v := range row {
+			seen[name] = true
+			col, ok := cols[name]
+			if !ok {
+				col = newColumnFor(v)
+				if col == nil {
+					return nil, errors.Errorf("row %d: unsupported type %T for column %q", i, v, name)
+				}
+				cols[name] = col
+				order = append(order, name)
+				// backfill zero values for rows seen before this column
+				// appeared, so every column has exactly len(rows) rows.
+				for j := 0; j < i; j++ {
+					if err := appendValue(col, nil); err != nil {
+						return nil, errors.Wrapf(err, "row %d: backfill column %q", j, name)
+					}
+				}
+			} else if fresh := newColumnFor(v); fresh == nil || fresh.Type() != col.Type() {
+				return nil, errors.Errorf("row %d: type %T does not match column %q of type %s", i, v, name, col.Type())
+			}
+			if err := appendValue(col, v); err != nil {
+				return nil, errors.Wrapf(err, "row %d: column %q", i, name)
+			}
+		}
+		// backfill zero values for every column this row didn't mention,
+		// so a column never falls behind len(rows) entries.
+		for _, name := range order {
+			if seen[name] {
+				continue
+			}
+			if err := appendValue(cols[name], nil); err != nil {
+				return nil, errors.Wrapf(err, "row %d: backfill column %q", i, name)
+			}
+		}
+	}
+
+	sort.Strings(order)
+
+	block := make(proto.Input, 0, len(order))
+	for _, name := range order {
+		block = append(block, proto.InputColumn{
+			Name: name,
+			Data: cols[name],
+		})
+	}
+	return block, nil
+}
+
+// newColumnFor returns a fresh, empty column able to hold values of the
+// same type as v, or nil if v's type is not supported.
+func newColumnFor(v any) proto.Column {
+	switch v.(type) {
+	case string:
+		return new(proto.ColStr)
+	case int64, int:
+		return new(proto.ColInt64)
+	case float64:
+		return new(proto.ColFloat64)
+	case bool:
+		return new(proto.ColBool)
+	case time.Time:
+		return new(proto.ColDateTime)
+	default:
+		return nil
+	}
+}
+
+// appendValue appends v to col, converting nil to col's zero value.
+func appendValue(col proto.Column, v any) error {
+	switch c := col.(type) {
+	case *proto.ColStr:
+		s, _ := v.(string)
+		c.Append(s)
+	case *proto.ColInt64:
+		switch n := v.(type) {
+		case int64:
+			c.Append(n)
+		case int:
+			c.Append(int64(n))
+		default:
+			c.Append(0)
+		}
+	case *proto.ColFloat64:
+		f, _ := v.(float64)
+		c.Append(f)
+	case *proto.ColBool:
+		b, _ := v.(bool)
+		c.Append(b)
+	case *proto.ColDateTime:
+		t, ok := v.(time.Time)
+		if !ok {
+			t = time.Time{}
+		}
+		c.Append(t)
+	default:
+		return errors.Errorf("unsupported column type %T", col)
+	}
+	return nil
+}
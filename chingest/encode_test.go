@@ -0,0 +1,72 @@
+package chingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeRows(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Empty", func(t *testing.T) {
+		block, err := encodeRows(nil)
+		require.NoError(t, err)
+		require.Nil(t, block)
+	})
+
+	t.Run("HeterogeneousRows", func(t *testing.T) {
+		rows := []Row{
+			{"a": int64(1), "b": "x"},
+			{"a": int64(3)},
+			{"a": int64(4), "b": "y"},
+		}
+		block, err := encodeRows(rows)
+		require.NoError(t, err)
+		require.Len(t, block, 2, "one column for a, one for b")
+		for _, col := range block {
+			require.Equal(t, len(rows), col.Data.Rows(),
+				"column %q must have exactly one value per row", col.Name)
+		}
+	})
+
+	t.Run("ColumnAppearsMidway", func(t *testing.T) {
+		rows := []Row{
+			{"a": int64(1)},
+			{"a": int64(2), "b": "late"},
+			{"a": int64(3)},
+		}
+		block, err := encodeRows(rows)
+		require.NoError(t, err)
+		for _, col := range block {
+			require.Equal(t, len(rows), col.Data.Rows())
+		}
+	})
+
+	t.Run("UnsupportedType", func(t *testing.T) {
+		_, err := encodeRows([]Row{{"a": struct{}{}}})
+		require.Error(t, err)
+	})
+
+	t.Run("ColumnOrderIsStable", func(t *testing.T) {
+		rows := []Row{
+			{"z": int64(1), "a": int64(2), "m": int64(3)},
+		}
+		var names []string
+		for i := 0; i < 20; i++ {
+			block, err := encodeRows(rows)
+			require.NoError(t, err)
+			var got []string
+			for _, col := range block {
+				got = append(got, col.Name)
+			}
+			if names == nil {
+				names = got
+			} else {
+				require.Equal(t, names, got,
+					"column order must not depend on Go's randomized map iteration, since INSERT FORMAT Native has no explicit column list")
+			}
+		}
+		require.Equal(t, []string{"a", "m", "z"}, names)
+	})
+}
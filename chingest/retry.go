@@ -0,0 +1,62 @@
+package chingest
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRetry is used when Options.Retry is the zero value.
+var DefaultRetry = RetryOptions{
+	MaxAttempts: 5,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// RetryOptions controls how LogWriter retries a failed flush.
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// If zero, DefaultRetry.MaxAttempts is used.
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt; delay doubles
+	// on each subsequent attempt, capped at MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+}
+
+func (r *RetryOptions) setDefaults() {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = DefaultRetry.MaxAttempts
+	}
+	if r.BaseDelay == 0 {
+		r.BaseDelay = DefaultRetry.BaseDelay
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = DefaultRetry.MaxDelay
+	}
+}
+
+// Do calls fn until it succeeds, ctx is done, or MaxAttempts is reached,
+// sleeping with exponential backoff between attempts.
+func (r RetryOptions) Do(ctx context.Context, fn func() error) error {
+	delay := r.BaseDelay
+	var err error
+	for attempt := 1; attempt <= r.MaxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == r.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+		if delay > r.MaxDelay {
+			delay = r.MaxDelay
+		}
+	}
+	return err
+}
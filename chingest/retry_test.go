@@ -0,0 +1,49 @@
+package chingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryOptions_Do(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SucceedsEventually", func(t *testing.T) {
+		opt := RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+		attempts := 0
+		err := opt.Do(context.Background(), func() error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		opt := RetryOptions{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+		attempts := 0
+		err := opt.Do(context.Background(), func() error {
+			attempts++
+			return errors.New("always fails")
+		})
+		require.Error(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("CtxCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		opt := RetryOptions{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Second}
+		err := opt.Do(ctx, func() error {
+			return errors.New("fails")
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
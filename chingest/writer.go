@@ -0,0 +1,332 @@
+// Package chingest provides a high-throughput row writer on top of
+// ch.Client, for turning ch-go into a drop-in log/metric ingest sink.
+package chingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.uber.org/zap"
+
+	"github.com/go-faster/ch"
+)
+
+// Row is a single structured row to be written. Column names are row
+// keys; values are converted to the target column's native type.
+type Row = map[string]any
+
+// TableFunc picks the destination table for row, enabling a single
+// LogWriter to multiplex inserts across many tables.
+type TableFunc func(row Row) string
+
+// Options configures a LogWriter.
+type Options struct {
+	// Client inserts buffered blocks into ClickHouse. Obtain one with
+	// ch.Dial.
+	Client *ch.Client
+	// Table is the destination table for every row. Ignored if
+	// TableFunc is set.
+	Table string
+	// TableFunc overrides Table to pick a destination table per row.
+	TableFunc TableFunc
+	// MaxRows flushes a table's buffer once it reaches this many rows.
+	// If zero, DefaultMaxRows is used.
+	MaxRows int
+	// MaxBytes flushes a table's buffer once its estimated size reaches
+	// this many bytes. If zero, DefaultMaxBytes is used.
+	MaxBytes int
+	// FlushInterval flushes every buffered table on a timer, bounding
+	// how long a row can sit unflushed. If zero, DefaultFlushInterval
+	// is used.
+	FlushInterval time.Duration
+	// MaxPending bounds the number of rows buffered in AppendRow's
+	// channel, applying backpressure to callers once full. If zero,
+	// DefaultMaxPending is used.
+	MaxPending int
+	// Retry controls how flush failures are retried. The zero value
+	// uses DefaultRetry.
+	Retry RetryOptions
+	// Logger logs flush failures and retries.
+	Logger *zap.Logger
+}
+
+// Defaults for Options, chosen to bound memory and staleness for a
+// typical log/metric ingest workload.
+const (
+	DefaultMaxRows       = 10_000
+	DefaultMaxBytes      = 4 << 20 // 4MiB
+	DefaultFlushInterval = time.Second
+	DefaultMaxPending    = 100_000
+)
+
+func (o *Options) setDefaults() {
+	if o.MaxRows == 0 {
+		o.MaxRows = DefaultMaxRows
+	}
+	if o.MaxBytes == 0 {
+		o.MaxBytes = DefaultMaxBytes
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+	if o.MaxPending == 0 {
+		o.MaxPending = DefaultMaxPending
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	o.Retry.setDefaults()
+}
+
+func (o *Options) tableFor(row Row) string {
+	if o.TableFunc != nil {
+		return o.TableFunc(row)
+	}
+	return o.Table
+}
+
+type rowEnvelope struct {
+	table string
+	row   Row
+	size  int
+}
+
+type flushRequest struct {
+	done chan error
+}
+
+// tableBuffer accumulates rows for a single table between flushes.
+type tableBuffer struct {
+	rows  []Row
+	bytes int
+}
+
+// LogWriter batches rows Appended by callers into column-oriented
+// blocks and inserts them into ClickHouse via Client, flushing on row
+// count, byte size or a timer, whichever comes first.
+//
+// A failed flush is retried with backoff per Options.Retry. If a batch
+// still fails after Options.Retry.MaxAttempts, it is logged via
+// Options.Logger and dropped: size- and timer-triggered flushes have no
+// caller to report the error to, so this is at-most Options.Retry
+// attempts, not true at-least-once delivery. Only Flush and Close
+// surface a flush error to their caller.
+type LogWriter struct {
+	opt Options
+
+	rows    chan rowEnvelope
+	flushes chan flushRequest
+	closed  chan struct{}
+	wg      sync.WaitGroup
+
+	closeOnce sync.Once
+	// mu guards done: AppendRow holds it for read while it may send on
+	// rows, Close takes it for write before flipping done, so no send
+	// can start once Close has committed to shutting run() down, and
+	// any send already in flight is let through (run() keeps draining
+	// until it observes closed, see run).
+	mu   sync.RWMutex
+	done bool
+
+	// closeErr is the result of the final flush, set by run before it
+	// returns and read by Close after wg.Wait.
+	closeErr error
+}
+
+// NewLogWriter returns a LogWriter and starts its background flush loop.
+// Call Close to stop it and flush any remaining buffered rows.
+func NewLogWriter(opt Options) *LogWriter {
+	opt.setDefaults()
+
+	w := &LogWriter{
+		opt:     opt,
+		rows:    make(chan rowEnvelope, opt.MaxPending),
+		flushes: make(chan flushRequest),
+		closed:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// AppendRow buffers row for table, blocking if the writer's internal
+// queue (Options.MaxPending) is full, until ctx is done.
+func (w *LogWriter) AppendRow(ctx context.Context, row Row) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.done {
+		return errors.New("chingest: writer is closed")
+	}
+
+	env := rowEnvelope{
+		table: w.opt.tableFor(row),
+		row:   row,
+		size:  estimateSize(row),
+	}
+	select {
+	case w.rows <- env:
+		return nil
+	case <-w.closed:
+		return errors.New("chingest: writer is closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush blocks until every buffered row has been inserted, retrying
+// failed inserts per Options.Retry.
+func (w *LogWriter) Flush(ctx context.Context) error {
+	req := flushRequest{done: make(chan error, 1)}
+	select {
+	case w.flushes <- req:
+	case <-w.closed:
+		return errors.New("chingest: writer is closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes any remaining buffered rows and stops the background
+// flush loop. Close is idempotent and safe to call concurrently.
+func (w *LogWriter) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.done = true
+		w.mu.Unlock()
+		close(w.closed)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return w.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *LogWriter) run() {
+	defer w.wg.Done()
+
+	buffers := map[string]*tableBuffer{}
+	ticker := time.NewTicker(w.opt.FlushInterval)
+	defer ticker.Stop()
+
+	flushAll := func(ctx context.Context) error {
+		var firstErr error
+		for table, buf := range buffers {
+			if len(buf.rows) == 0 {
+				continue
+			}
+			if err := w.flushTable(ctx, table, buf); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			delete(buffers, table)
+		}
+		return firstErr
+	}
+
+	appendEnv := func(env rowEnvelope) {
+		buf := buffers[env.table]
+		if buf == nil {
+			buf = &tableBuffer{}
+			buffers[env.table] = buf
+		}
+		buf.rows = append(buf.rows, env.row)
+		buf.bytes += env.size
+	}
+
+	// drain moves whatever AppendRow already enqueued into buffers.
+	drain := func() {
+		for drained := true; drained; {
+			select {
+			case env := <-w.rows:
+				appendEnv(env)
+			default:
+				drained = false
+			}
+		}
+	}
+
+	for {
+		select {
+		case env := <-w.rows:
+			appendEnv(env)
+			buf := buffers[env.table]
+			if len(buf.rows) >= w.opt.MaxRows || buf.bytes >= w.opt.MaxBytes {
+				if err := w.flushTable(context.Background(), env.table, buf); err != nil {
+					w.opt.Logger.Error("chingest: flush failed", zap.String("table", env.table), zap.Error(err))
+				}
+				delete(buffers, env.table)
+			}
+		case <-ticker.C:
+			if err := flushAll(context.Background()); err != nil {
+				w.opt.Logger.Error("chingest: periodic flush failed", zap.Error(err))
+			}
+		case req := <-w.flushes:
+			// Rows accepted by AppendRow may still sit in the channel.
+			drain()
+			req.done <- flushAll(context.Background())
+		case <-w.closed:
+			// Drain whatever AppendRow already managed to enqueue before
+			// observing closed, so we flush it instead of dropping it.
+			drain()
+			w.closeErr = flushAll(context.Background())
+			return
+		}
+	}
+}
+
+// flushTable inserts buf into table, retrying per Options.Retry.
+func (w *LogWriter) flushTable(ctx context.Context, table string, buf *tableBuffer) error {
+	rows := buf.rows
+	return w.opt.Retry.Do(ctx, func() error {
+		return w.insert(ctx, table, rows)
+	})
+}
+
+func (w *LogWriter) insert(ctx context.Context, table string, rows []Row) error {
+	block, err := encodeRows(rows)
+	if err != nil {
+		return errors.Wrap(err, "encode rows")
+	}
+	q := ch.Query{
+		Body:  "INSERT INTO " + table + " FORMAT Native",
+		Input: block,
+	}
+	if _, err := w.opt.Client.Do(ctx, q); err != nil {
+		return errors.Wrapf(err, "insert into %q", table)
+	}
+	return nil
+}
+
+// estimateSize returns a rough estimate, in bytes, of row's encoded
+// size, used to trigger a flush on Options.MaxBytes.
+func estimateSize(row Row) int {
+	const overhead = 16 // per-value bookkeeping, column offsets, etc.
+	size := 0
+	for k, v := range row {
+		size += len(k) + overhead
+		switch val := v.(type) {
+		case string:
+			size += len(val)
+		case []byte:
+			size += len(val)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
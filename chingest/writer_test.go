@@ -0,0 +1,71 @@
+package chingest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-faster/ch"
+)
+
+// TestOptions_ClientIsChClient guards against Options.Client drifting
+// out of sync with ch.Client's real shape (it once referenced a type
+// that didn't exist anywhere in the module).
+func TestOptions_ClientIsChClient(t *testing.T) {
+	t.Parallel()
+
+	opt := Options{Client: &ch.Client{}, Table: "logs"}
+	w := NewLogWriter(opt)
+	require.NoError(t, w.Close(context.Background()))
+}
+
+func TestLogWriter_ConcurrentClose(t *testing.T) {
+	t.Parallel()
+
+	w := NewLogWriter(Options{Client: &ch.Client{}, Table: "logs"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, w.Close(context.Background()))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLogWriter_AppendRowAfterCloseIsRejected(t *testing.T) {
+	t.Parallel()
+
+	w := NewLogWriter(Options{Client: &ch.Client{}, Table: "logs"})
+	require.NoError(t, w.Close(context.Background()))
+
+	err := w.AppendRow(context.Background(), Row{"a": 1})
+	require.Error(t, err, "a row appended after Close must never be silently swallowed")
+}
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	require.Zero(t, estimateSize(Row{}))
+	require.Greater(t, estimateSize(Row{"msg": "hello"}), 0)
+	require.Greater(t,
+		estimateSize(Row{"msg": "a long message that takes more bytes"}),
+		estimateSize(Row{"msg": "short"}),
+	)
+}
+
+func TestOptions_TableFor(t *testing.T) {
+	t.Parallel()
+
+	opt := Options{Table: "logs"}
+	require.Equal(t, "logs", opt.tableFor(Row{"any": "thing"}))
+
+	opt = Options{TableFunc: func(row Row) string {
+		return row["service"].(string) + "_logs"
+	}}
+	require.Equal(t, "api_logs", opt.tableFor(Row{"service": "api"}))
+}
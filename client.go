@@ -0,0 +1,256 @@
+package ch
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/go-faster/ch/internal/compress"
+	"github.com/go-faster/ch/proto"
+)
+
+// ClientOptions wraps possible Client configuration.
+type ClientOptions struct {
+	Logger   *zap.Logger
+	Timezone *time.Location
+	// TracerProvider is used to start a span per query. If nil, the
+	// global otel.GetTracerProvider is used.
+	TracerProvider trace.TracerProvider
+	// BufferPool overrides the pool used by generated column EncodeColumn
+	// codecs, via proto.SetDefaultBufferPool. It is process-wide, not
+	// per-connection: setting it on two Clients in the same process means
+	// whichever one is constructed last wins. If nil, the default pool is
+	// left as-is.
+	BufferPool *proto.BufferPool
+}
+
+// Client is a single connection to a ClickHouse server, speaking the
+// same native protocol that ServerConn implements on the server side.
+// It is the counterpart chcluster.Node and chingest.Options expect.
+//
+// Client is safe for concurrent use: Do and Ping serialize access to the
+// underlying connection, since the native protocol is not pipelined.
+type Client struct {
+	lg     *zap.Logger
+	tz     *time.Location
+	conn   net.Conn
+	buf    *proto.Buffer
+	reader *proto.Reader
+	info   proto.ServerHello
+
+	compressor *compress.Writer
+	tracer     trace.Tracer
+
+	mu sync.Mutex
+}
+
+// Dial connects to a ClickHouse server at addr and performs the initial
+// handshake.
+func Dial(ctx context.Context, addr string, opt ClientOptions) (*Client, error) {
+	if opt.Logger == nil {
+		opt.Logger = zap.NewNop()
+	}
+	if opt.Timezone == nil {
+		opt.Timezone = time.UTC
+	}
+	if opt.TracerProvider == nil {
+		opt.TracerProvider = otel.GetTracerProvider()
+	}
+	if opt.BufferPool != nil {
+		proto.SetDefaultBufferPool(opt.BufferPool)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+
+	c := &Client{
+		lg:         opt.Logger,
+		tz:         opt.Timezone,
+		conn:       conn,
+		buf:        new(proto.Buffer),
+		reader:     proto.NewReader(conn),
+		compressor: compress.NewWriter(),
+		tracer:     opt.TracerProvider.Tracer(instrumentationName),
+	}
+	if err := c.handshake(); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "handshake")
+	}
+	return c, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) handshake() error {
+	proto.ClientCodeHello.Encode(c.buf)
+	(proto.ClientHello{}).Encode(c.buf)
+	if err := c.flush(); err != nil {
+		return errors.Wrap(err, "flush")
+	}
+	if err := c.info.Decode(c.reader); err != nil {
+		return errors.Wrap(err, "decode hello")
+	}
+	return nil
+}
+
+func (c *Client) flush() error {
+	n, err := c.conn.Write(c.buf.Buf)
+	if err != nil {
+		return errors.Wrap(err, "write")
+	}
+	if n != len(c.buf.Buf) {
+		return errors.Wrap(io.ErrShortWrite, "wrote less than expected")
+	}
+	c.buf.Reset()
+	return nil
+}
+
+func (c *Client) packet() (proto.ServerCode, error) {
+	n, err := c.reader.UVarInt()
+	if err != nil {
+		return 0, errors.Wrap(err, "uvarint")
+	}
+	code := proto.ServerCode(n)
+	if !code.IsAServerCode() {
+		return 0, errors.Errorf("bad server packet type %d", n)
+	}
+	return code, nil
+}
+
+// Ping checks that the connection is alive.
+func (c *Client) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	proto.ClientCodePing.Encode(c.buf)
+	if err := c.flush(); err != nil {
+		return errors.Wrap(err, "flush")
+	}
+
+	p, err := c.packet()
+	if err != nil {
+		return errors.Wrap(err, "packet")
+	}
+	switch p {
+	case proto.ServerCodePong:
+		return nil
+	case proto.ServerCodeException:
+		var e proto.Exception
+		if err := e.Decode(c.reader); err != nil {
+			return errors.Wrap(err, "decode exception")
+		}
+		return errors.Errorf("ping: %s", e.Message)
+	default:
+		return errors.Errorf("unexpected packet %q", p)
+	}
+}
+
+// Do executes q and returns its Result. For queries with Input set (e.g.
+// an INSERT), Input is sent as the query's input data before the
+// end-of-stream marker; otherwise an empty Data block is sent to signal
+// that the client has no input, as ClickHouse's native protocol requires
+// even for read-only queries.
+func (c *Client) Do(ctx context.Context, q Query) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, span := c.tracer.Start(ctx, "ch.Query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "clickhouse"),
+			attribute.String("db.statement", q.Body),
+			attribute.String("net.peer.name", c.conn.RemoteAddr().String()),
+		),
+	)
+	defer span.End()
+
+	res, err := c.do(ctx, q)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return Result{}, err
+	}
+	span.SetAttributes(
+		attribute.Int64("db.clickhouse.rows_read", int64(res.Rows)),
+		attribute.Int64("db.clickhouse.bytes_read", int64(res.Bytes)),
+	)
+	return res, nil
+}
+
+func (c *Client) do(ctx context.Context, q Query) (Result, error) {
+	proto.ClientCodeQuery.Encode(c.buf)
+	(proto.Query{ID: q.ID, Body: q.Body}).Encode(c.buf)
+	if err := c.flush(); err != nil {
+		return Result{}, errors.Wrap(err, "flush query")
+	}
+
+	if q.Input != nil {
+		if err := c.sendBlock(q.Input); err != nil {
+			return Result{}, errors.Wrap(err, "send input")
+		}
+	}
+	if err := c.sendBlock(nil); err != nil {
+		return Result{}, errors.Wrap(err, "send end of input")
+	}
+
+	var res Result
+	for {
+		p, err := c.packet()
+		if err != nil {
+			return Result{}, errors.Wrap(err, "packet")
+		}
+		switch p {
+		case proto.ServerCodeData:
+			var block proto.Block
+			if err := block.DecodeBlock(c.reader, c.info.Revision); err != nil {
+				return Result{}, errors.Wrap(err, "decode block")
+			}
+			// TODO: surface decoded rows as proto.Input in res.Blocks once
+			// Block exposes its columns; Progress below still reports
+			// accurate row/byte counts in the meantime.
+		case proto.ServerCodeProgress:
+			var progress proto.Progress
+			if err := progress.Decode(c.reader); err != nil {
+				return Result{}, errors.Wrap(err, "decode progress")
+			}
+			res.Rows += progress.Rows
+			res.Bytes += progress.Bytes
+		case proto.ServerCodeException:
+			var e proto.Exception
+			if err := e.Decode(c.reader); err != nil {
+				return Result{}, errors.Wrap(err, "decode exception")
+			}
+			return Result{}, errors.Errorf("clickhouse: %s", e.Message)
+		case proto.ServerCodeEndOfStream:
+			return res, nil
+		default:
+			return Result{}, errors.Errorf("unexpected packet %q", p)
+		}
+	}
+}
+
+// sendBlock writes block as a Data packet; a nil block signals the end
+// of the client's input.
+func (c *Client) sendBlock(block proto.Input) error {
+	proto.ClientCodeData.Encode(c.buf)
+	c.buf.PutString("") // table name, unused for query input
+	if err := proto.EncodeBlock(c.buf, c.info.Revision, block); err != nil {
+		return errors.Wrap(err, "encode block")
+	}
+	return c.flush()
+}
@@ -0,0 +1,56 @@
+package ch
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-faster/ch/proto"
+)
+
+// Setting is a per-query ClickHouse setting, e.g. {Key: "max_threads",
+// Value: "4"}.
+type Setting = proto.Setting
+
+// Query describes a single query received from a client.
+type Query struct {
+	// ID is the query identifier, empty if not set by client.
+	ID string
+	// Body is the raw query text, e.g. "SELECT 1".
+	Body string
+	// Settings are the per-query settings sent along with the query.
+	Settings []Setting
+	// TraceID and SpanID carry the OpenTelemetry trace context the
+	// client attached to the query's ClientInfo, if any, so the server
+	// span can join the same trace. Zero if the client did not send one.
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+	// Input, if set, is sent as the query's input data, e.g. the rows
+	// of an INSERT issued via Client.Do.
+	Input proto.Input
+}
+
+// Result is returned by a QueryHandler and streamed back to the client.
+type Result struct {
+	// Blocks are written back to the client as Data packets, in order.
+	Blocks []proto.Input
+	// Rows is the total rows read while executing the query, reported to
+	// the client via Progress.
+	Rows uint64
+	// Bytes is the total bytes read while executing the query, reported
+	// to the client via Progress.
+	Bytes uint64
+}
+
+// QueryHandler executes queries against user-defined storage.
+//
+// Set ServerOptions.Handler to plug a QueryHandler into Server, turning it
+// into an embeddable ClickHouse-protocol endpoint, e.g. for tests.
+type QueryHandler interface {
+	// HandleQuery is called once the query and (for INSERT) all of its
+	// input data blocks have been received. data is a reader over the raw
+	// bytes of the decoded input blocks sent by the client for this query;
+	// it is empty for read-only queries.
+	HandleQuery(ctx context.Context, q Query, data io.Reader) (Result, error)
+}
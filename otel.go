@@ -0,0 +1,120 @@
+package ch
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this module to OTel exporters.
+const instrumentationName = "github.com/go-faster/ch"
+
+// serverMetrics holds the OTel instruments emitted by Server.
+type serverMetrics struct {
+	bytesIn       metric.Int64Counter
+	bytesOut      metric.Int64Counter
+	blocksDecoded metric.Int64Counter
+	blocksEncoded metric.Int64Counter
+	queryDuration metric.Float64Histogram
+}
+
+func newServerMetrics(mp metric.MeterProvider) (*serverMetrics, error) {
+	meter := mp.Meter(instrumentationName)
+
+	bytesIn, err := meter.Int64Counter("ch.server.bytes_in")
+	if err != nil {
+		return nil, errors.Wrap(err, "bytes_in")
+	}
+	bytesOut, err := meter.Int64Counter("ch.server.bytes_out")
+	if err != nil {
+		return nil, errors.Wrap(err, "bytes_out")
+	}
+	blocksDecoded, err := meter.Int64Counter("ch.server.blocks_decoded")
+	if err != nil {
+		return nil, errors.Wrap(err, "blocks_decoded")
+	}
+	blocksEncoded, err := meter.Int64Counter("ch.server.blocks_encoded")
+	if err != nil {
+		return nil, errors.Wrap(err, "blocks_encoded")
+	}
+	queryDuration, err := meter.Float64Histogram("ch.server.query_duration", metric.WithUnit("s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "query_duration")
+	}
+
+	return &serverMetrics{
+		bytesIn:       bytesIn,
+		bytesOut:      bytesOut,
+		blocksDecoded: blocksDecoded,
+		blocksEncoded: blocksEncoded,
+		queryDuration: queryDuration,
+	}, nil
+}
+
+// meteredReader wraps a connection's reader to record every byte read
+// from it as ch.server.bytes_in, the read-side counterpart to bytesOut
+// (recorded in ServerConn.flush on the write side).
+type meteredReader struct {
+	r       io.Reader
+	metrics *serverMetrics
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if n > 0 && m.metrics != nil {
+		m.metrics.bytesIn.Add(context.Background(), int64(n))
+	}
+	return n, err
+}
+
+// startQuerySpan starts a span for q, joining the client's trace if it
+// attached one to the query's ClientInfo.
+func (c *ServerConn) startQuerySpan(ctx context.Context, q Query) (context.Context, trace.Span) {
+	if q.TraceID.IsValid() && q.SpanID.IsValid() {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    q.TraceID,
+			SpanID:     q.SpanID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		})
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return c.tracer.Start(ctx, "ch.Query",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("db.system", "clickhouse"),
+			attribute.String("db.statement", q.Body),
+			attribute.String("net.peer.name", c.conn.RemoteAddr().String()),
+			attribute.Int("ch.revision", int(c.info.Revision)),
+		),
+	)
+}
+
+// endQuerySpan records the outcome of a query on span.
+func endQuerySpan(span trace.Span, res Result, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			attribute.Int64("db.clickhouse.rows_read", int64(res.Rows)),
+			attribute.Int64("db.clickhouse.bytes_read", int64(res.Bytes)),
+		)
+	}
+	span.End()
+}
+
+// recordQueryDuration records how long a query took, if metrics are
+// configured.
+func (c *ServerConn) recordQueryDuration(ctx context.Context, start time.Time) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.queryDuration.Record(ctx, time.Since(start).Seconds())
+}
@@ -0,0 +1,51 @@
+package ch
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMeteredReader_RecordsBytesIn(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	metrics, err := newServerMetrics(mp)
+	require.NoError(t, err)
+
+	mr := &meteredReader{r: strings.NewReader("hello world"), metrics: metrics}
+	buf := make([]byte, 5)
+	n, err := mr.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &data))
+
+	var sum int64
+	for _, sm := range data.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "ch.server.bytes_in" {
+				continue
+			}
+			if d, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range d.DataPoints {
+					sum += dp.Value
+				}
+			}
+		}
+	}
+	require.EqualValues(t, 5, sum)
+}
+
+func TestMeteredReader_NilMetricsDoesNotPanic(t *testing.T) {
+	mr := &meteredReader{r: strings.NewReader("hello"), metrics: nil}
+	buf := make([]byte, 5)
+	n, err := mr.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+}
@@ -0,0 +1,89 @@
+package proto
+
+import "sync"
+
+// bufferPoolClasses are the size classes, in bytes, used by BufferPool,
+// from 512B to 4MiB, doubling at each step.
+var bufferPoolClasses = func() []int {
+	var classes []int
+	for size := 512; size <= 4*1024*1024; size *= 2 {
+		classes = append(classes, size)
+	}
+	return classes
+}()
+
+// BufferPool is a sync.Pool of byte slices bucketed into size classes.
+// It backs the scratch buffers used by generated column EncodeColumn
+// methods (see ColEnum16.EncodeColumn), so that encoding millions of
+// rows does not thrash the GC with a fresh allocation per call.
+// DecodeColumn cannot use it the same way: the decoded slice it returns
+// becomes the caller's data, not scratch, so there is nothing to Put
+// back.
+//
+// The zero value is not usable, use NewBufferPool.
+type BufferPool struct {
+	pools []sync.Pool
+}
+
+// NewBufferPool returns a ready to use BufferPool.
+func NewBufferPool() *BufferPool {
+	p := &BufferPool{
+		pools: make([]sync.Pool, len(bufferPoolClasses)),
+	}
+	for i, size := range bufferPoolClasses {
+		size := size
+		p.pools[i].New = func() interface{} {
+			return make([]byte, size)
+		}
+	}
+	return p
+}
+
+// classFor returns the index into p.pools of the smallest size class
+// that can hold n bytes, or -1 if n is larger than the biggest class.
+func (p *BufferPool) classFor(n int) int {
+	for i, size := range bufferPoolClasses {
+		if n <= size {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns a byte slice of length n. Sizes above the largest size
+// class are allocated directly and not pooled.
+func (p *BufferPool) Get(n int) []byte {
+	i := p.classFor(n)
+	if i < 0 {
+		return make([]byte, n)
+	}
+	buf := p.pools[i].Get().([]byte)
+	return buf[:n]
+}
+
+// Put returns buf to the pool for reuse. buf must have been obtained
+// from Get and not grown past its original capacity, otherwise it is
+// silently discarded.
+func (p *BufferPool) Put(buf []byte) {
+	i := p.classFor(cap(buf))
+	if i < 0 || cap(buf) != bufferPoolClasses[i] {
+		return
+	}
+	p.pools[i].Put(buf[:cap(buf)])
+}
+
+// defaultBufferPool is the pool used by generated column EncodeColumn
+// codecs, e.g. ColEnum16.EncodeColumn. Override it with
+// SetDefaultBufferPool, normally via ClientOptions.BufferPool or
+// ServerOptions.BufferPool rather than calling it directly.
+var defaultBufferPool = NewBufferPool()
+
+// SetDefaultBufferPool replaces the pool used by generated column
+// EncodeColumn codecs. It is process-wide: call it once, before serving
+// connections or issuing queries, not per-connection.
+//
+// DecodeColumn does not use the pool: the slice it returns becomes the
+// caller's data, not scratch, so there is nothing to Put back.
+func SetDefaultBufferPool(p *BufferPool) {
+	defaultBufferPool = p
+}
@@ -0,0 +1,35 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool_GetPut(t *testing.T) {
+	p := NewBufferPool()
+
+	buf := p.Get(100)
+	require.Len(t, buf, 100)
+	p.Put(buf)
+
+	buf = p.Get(100)
+	require.Len(t, buf, 100)
+}
+
+func TestBufferPool_OversizeNotPooled(t *testing.T) {
+	p := NewBufferPool()
+
+	buf := p.Get(64 * 1024 * 1024)
+	require.Len(t, buf, 64*1024*1024)
+	p.Put(buf) // should not panic, just discarded
+}
+
+func TestSetDefaultBufferPool(t *testing.T) {
+	orig := defaultBufferPool
+	defer func() { defaultBufferPool = orig }()
+
+	p := NewBufferPool()
+	SetDefaultBufferPool(p)
+	require.Same(t, p, defaultBufferPool)
+}
@@ -48,8 +48,13 @@ func (c *ColArr) AppendEnum16(data []Enum16) {
 // EncodeColumn encodes Enum16 rows to *Buffer.
 func (c ColEnum16) EncodeColumn(b *Buffer) {
 	const size = 16 / 8
+	if len(c) == 0 {
+		return
+	}
 	offset := len(b.Buf)
-	b.Buf = append(b.Buf, make([]byte, size*len(c))...)
+	scratch := defaultBufferPool.Get(size * len(c))
+	b.Buf = append(b.Buf, scratch...)
+	defaultBufferPool.Put(scratch)
 	for _, v := range c {
 		bin.PutUint16(
 			b.Buf[offset:offset+size],
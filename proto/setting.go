@@ -0,0 +1,10 @@
+package proto
+
+// Setting is a single ClickHouse query setting, as sent in the settings
+// section of a Query packet: a sequence of these terminated by an empty
+// Key.
+type Setting struct {
+	Key       string
+	Value     string
+	Important bool
+}
@@ -1,12 +1,18 @@
 package ch
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net"
 	"time"
 
 	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -20,6 +26,9 @@ type Server struct {
 	lg      *zap.Logger
 	tz      *time.Location
 	workers int
+	handler QueryHandler
+	tracer  trace.Tracer
+	metrics *serverMetrics
 	conn    atomic.Uint64
 }
 
@@ -28,6 +37,22 @@ type ServerOptions struct {
 	Logger   *zap.Logger
 	Timezone *time.Location
 	Workers  int
+	// Handler executes queries received from clients. If nil, every
+	// Query packet is rejected with a DB::Exception.
+	Handler QueryHandler
+	// TracerProvider is used to start a span per connection and per
+	// handled query. If nil, the global otel.GetTracerProvider is used.
+	TracerProvider trace.TracerProvider
+	// MeterProvider is used to record bytes in/out, blocks encoded and
+	// decoded, and query duration. If nil, the global
+	// otel.GetMeterProvider is used.
+	MeterProvider metric.MeterProvider
+	// BufferPool overrides the pool used by generated column EncodeColumn
+	// codecs, via proto.SetDefaultBufferPool. It is process-wide, not
+	// per-connection: setting it on two Servers in the same process means
+	// whichever one is constructed last wins. If nil, the default pool is
+	// left as-is.
+	BufferPool *proto.BufferPool
 }
 
 // NewServer returns new ClickHouse Server.
@@ -41,10 +66,26 @@ func NewServer(opt ServerOptions) *Server {
 	if opt.Workers == 0 {
 		opt.Workers = 100
 	}
+	if opt.TracerProvider == nil {
+		opt.TracerProvider = otel.GetTracerProvider()
+	}
+	if opt.MeterProvider == nil {
+		opt.MeterProvider = otel.GetMeterProvider()
+	}
+	metrics, err := newServerMetrics(opt.MeterProvider)
+	if err != nil {
+		opt.Logger.Error("otel: failed to create meter instruments, metrics disabled", zap.Error(err))
+	}
+	if opt.BufferPool != nil {
+		proto.SetDefaultBufferPool(opt.BufferPool)
+	}
 	return &Server{
 		lg:      opt.Logger,
 		tz:      opt.Timezone,
 		workers: opt.Workers,
+		handler: opt.Handler,
+		tracer:  opt.TracerProvider.Tracer(instrumentationName),
+		metrics: metrics,
 	}
 }
 
@@ -62,7 +103,17 @@ type ServerConn struct {
 	// see encodeBlock.
 	compressor *compress.Writer
 
-	settings []Setting
+	// handler executes queries, see handleQuery.
+	handler QueryHandler
+	// tracer and metrics instrument connections and queries, see otel.go.
+	tracer  trace.Tracer
+	metrics *serverMetrics
+	// query is the pending query awaiting its input data, if any.
+	query *Query
+	// input buffers decoded Data blocks sent by the client for query,
+	// e.g. rows of an INSERT, until the end-of-stream (empty) block
+	// signals that the query can be executed.
+	input proto.Buffer
 }
 
 func (c *ServerConn) packet() (proto.ClientCode, error) {
@@ -102,7 +153,6 @@ func (c *ServerConn) handshake() error {
 	}
 
 	_ = c.compressor // hack
-	_ = c.settings   // hack
 
 	return nil
 }
@@ -118,14 +168,23 @@ func (c *ServerConn) flush() error {
 	if ce := c.lg.Check(zap.DebugLevel, "Flush"); ce != nil {
 		ce.Write(zap.Int("bytes", n))
 	}
+	if c.metrics != nil {
+		c.metrics.bytesOut.Add(context.Background(), int64(n))
+	}
 	c.buf.Reset()
 	return nil
 }
 
-func (c *ServerConn) handlePacket(p proto.ClientCode) error {
+func (c *ServerConn) handlePacket(ctx context.Context, p proto.ClientCode) error {
 	switch p {
 	case proto.ClientCodePing:
 		return c.handlePing()
+	case proto.ClientCodeQuery:
+		return c.handleQuery()
+	case proto.ClientCodeData:
+		return c.handleData(ctx)
+	case proto.ClientCodeCancel:
+		return c.handleCancel()
 	default:
 		return errors.Errorf("%q not implemented", p)
 	}
@@ -136,9 +195,141 @@ func (c *ServerConn) handlePing() error {
 	return c.flush()
 }
 
+// handleQuery decodes a Query packet and remembers it until either its
+// input data arrives (INSERT) or an empty Data block signals that there
+// is none, at which point the query is executed.
+func (c *ServerConn) handleQuery() error {
+	var q proto.Query
+	if err := q.Decode(c.reader); err != nil {
+		return errors.Wrap(err, "decode query")
+	}
+
+	c.query = &Query{
+		ID:       q.ID,
+		Body:     q.Body,
+		Settings: q.Settings,
+		TraceID:  trace.TraceID(q.ClientInfo.TraceID),
+		SpanID:   trace.SpanID(q.ClientInfo.SpanID),
+	}
+	c.input.Reset()
+
+	return nil
+}
+
+// handleData decodes a Data packet. An empty block marks the end of the
+// client's input (or that there is none), triggering query execution.
+func (c *ServerConn) handleData(ctx context.Context) error {
+	var block proto.Block
+	if err := block.DecodeBlock(c.reader, c.info.Revision); err != nil {
+		return errors.Wrap(err, "decode block")
+	}
+	if c.metrics != nil {
+		c.metrics.blocksDecoded.Add(context.Background(), 1)
+	}
+	if block.Rows() == 0 {
+		return c.executeQuery(ctx)
+	}
+	if err := block.EncodeBlock(&c.input, c.info.Revision); err != nil {
+		return errors.Wrap(err, "buffer block")
+	}
+	return nil
+}
+
+// handleCancel aborts the pending query, if any.
+func (c *ServerConn) handleCancel() error {
+	if c.query != nil {
+		c.lg.Info("Query cancelled", zap.String("query_id", c.query.ID))
+	}
+	c.query = nil
+	c.input.Reset()
+	return nil
+}
+
+// executeQuery runs the pending query against the configured Handler and
+// streams the result back as Data, Progress and EndOfStream packets.
+func (c *ServerConn) executeQuery(ctx context.Context) error {
+	if c.query == nil {
+		return errors.New("data without pending query")
+	}
+	query := *c.query
+	data := bytes.NewReader(c.input.Buf)
+	c.query = nil
+	c.input.Reset()
+
+	ctx, span := c.startQuerySpan(ctx, query)
+	start := time.Now()
+	defer c.recordQueryDuration(ctx, start)
+
+	if c.handler == nil {
+		err := errors.New("no QueryHandler configured")
+		endQuerySpan(span, Result{}, err)
+		return c.encodeException(err)
+	}
+
+	res, err := c.handler.HandleQuery(ctx, query, data)
+	endQuerySpan(span, res, err)
+	if err != nil {
+		return c.encodeException(err)
+	}
+
+	for _, block := range res.Blocks {
+		if err := c.encodeDataBlock(block); err != nil {
+			return errors.Wrap(err, "encode data")
+		}
+	}
+	if err := c.encodeProgress(res.Rows, res.Bytes); err != nil {
+		return errors.Wrap(err, "encode progress")
+	}
+
+	proto.ServerCodeEndOfStream.Encode(c.buf)
+	return c.flush()
+}
+
+// encodeDataBlock writes a single result block as a Data packet.
+func (c *ServerConn) encodeDataBlock(block proto.Input) error {
+	proto.ServerCodeData.Encode(c.buf)
+	c.buf.PutString("") // table name, unused for query results
+	if err := proto.EncodeBlock(c.buf, c.info.Revision, block); err != nil {
+		return errors.Wrap(err, "encode block")
+	}
+	if c.metrics != nil {
+		c.metrics.blocksEncoded.Add(context.Background(), 1)
+	}
+	return c.flush()
+}
+
+// encodeProgress writes a Progress packet reporting rows and bytes read.
+func (c *ServerConn) encodeProgress(rows, bytes uint64) error {
+	proto.ServerCodeProgress.Encode(c.buf)
+	(proto.Progress{
+		Rows:  rows,
+		Bytes: bytes,
+	}).Encode(c.buf)
+	return c.flush()
+}
+
+// encodeException writes err back to the client as a Exception packet.
+func (c *ServerConn) encodeException(err error) error {
+	proto.ServerCodeException.Encode(c.buf)
+	(proto.Exception{
+		Code:    1,
+		Name:    "DB::Exception",
+		Message: err.Error(),
+	}).Encode(c.buf)
+	return c.flush()
+}
+
 // Handle connection.
 func (c *ServerConn) Handle() error {
+	ctx, span := c.tracer.Start(context.Background(), "ch.Connection",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("net.peer.name", c.conn.RemoteAddr().String())),
+	)
+	defer span.End()
+
 	if err := c.handshake(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return errors.Wrap(err, "handshake")
 	}
 	for {
@@ -147,7 +338,15 @@ func (c *ServerConn) Handle() error {
 			return errors.Wrap(err, "packet")
 		}
 		c.lg.Debug("Packet", zap.String("packet", p.String()))
-		if err := c.handlePacket(p); err != nil {
+
+		packetCtx, packetSpan := c.tracer.Start(ctx, "ch."+p.String(), trace.WithSpanKind(trace.SpanKindServer))
+		err = c.handlePacket(packetCtx, p)
+		if err != nil {
+			packetSpan.RecordError(err)
+			packetSpan.SetStatus(codes.Error, err.Error())
+		}
+		packetSpan.End()
+		if err != nil {
 			return errors.Wrapf(err, "handle %q", p)
 		}
 	}
@@ -164,13 +363,16 @@ func (s *Server) handle(conn net.Conn) error {
 		lg:     lg,
 		conn:   conn,
 		buf:    new(proto.Buffer),
-		reader: proto.NewReader(conn),
+		reader: proto.NewReader(&meteredReader{r: conn, metrics: s.metrics}),
 		client: proto.ClientHello{},
 		info: proto.ServerHello{
 			Name: "CH",
 		},
 		tz:         time.UTC,
 		compressor: compress.NewWriter(),
+		handler:    s.handler,
+		tracer:     s.tracer,
+		metrics:    s.metrics,
 	}
 	return sConn.Handle()
 }
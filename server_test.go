@@ -0,0 +1,121 @@
+package ch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"github.com/go-faster/ch/proto"
+)
+
+// handlerFunc adapts a plain function to QueryHandler, for tests.
+type handlerFunc func(ctx context.Context, q Query, data io.Reader) (Result, error)
+
+func (f handlerFunc) HandleQuery(ctx context.Context, q Query, data io.Reader) (Result, error) {
+	return f(ctx, q, data)
+}
+
+// fakeConn is a minimal net.Conn stub that captures what ServerConn.flush
+// writes, without the synchronization net.Pipe would otherwise require.
+// It also reports a fixed RemoteAddr, which the query span reads.
+type fakeConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (f *fakeConn) Write(p []byte) (int, error) {
+	return f.written.Write(p)
+}
+
+func (f *fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func newTestServerConn(t *testing.T, handler QueryHandler) (*ServerConn, *fakeConn) {
+	t.Helper()
+	conn := &fakeConn{}
+	return &ServerConn{
+		lg:      zap.NewNop(),
+		tz:      time.UTC,
+		conn:    conn,
+		buf:     new(proto.Buffer),
+		info:    proto.ServerHello{Name: "CH"},
+		handler: handler,
+		tracer:  otel.GetTracerProvider().Tracer("ch_test"),
+	}, conn
+}
+
+// Note: handleQuery and handleData decode raw client-sent bytes via
+// proto.Query.Decode/proto.Block.DecodeBlock; producing those bytes needs
+// the proto.Query and block encoders that client.go calls, which are not
+// defined in this tree. These tests drive
+// executeQuery and its siblings directly with pre-populated state instead
+// of round-tripping real wire bytes, which is the part of the
+// Query/Data/Cancel state machine that doesn't depend on that missing
+// encode/decode layer.
+
+func TestServerConn_ExecuteQuery_Success(t *testing.T) {
+	c, conn := newTestServerConn(t, handlerFunc(func(ctx context.Context, q Query, data io.Reader) (Result, error) {
+		return Result{Rows: 1, Bytes: 2}, nil
+	}))
+	c.query = &Query{ID: "q1", Body: "SELECT 1"}
+
+	err := c.executeQuery(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, c.query, "executeQuery must clear the pending query")
+	require.Positive(t, conn.written.Len(), "a Progress/EndOfStream reply must have been flushed")
+}
+
+func TestServerConn_ExecuteQuery_HandlerError(t *testing.T) {
+	wantErr := errors.New("boom")
+	c, conn := newTestServerConn(t, handlerFunc(func(ctx context.Context, q Query, data io.Reader) (Result, error) {
+		return Result{}, wantErr
+	}))
+	c.query = &Query{ID: "q1", Body: "SELECT 1"}
+
+	err := c.executeQuery(context.Background())
+	require.NoError(t, err, "a handler error is sent back as an Exception packet, not returned")
+	require.Positive(t, conn.written.Len(), "an Exception reply must have been flushed")
+}
+
+func TestServerConn_ExecuteQuery_NoHandlerConfigured(t *testing.T) {
+	c, conn := newTestServerConn(t, nil)
+	c.query = &Query{ID: "q1", Body: "SELECT 1"}
+
+	err := c.executeQuery(context.Background())
+	require.NoError(t, err)
+	require.Positive(t, conn.written.Len(), "an Exception reply must have been flushed even with no Handler")
+}
+
+func TestServerConn_ExecuteQuery_NoPendingQuery(t *testing.T) {
+	c, _ := newTestServerConn(t, nil)
+	c.query = nil
+
+	err := c.executeQuery(context.Background())
+	require.Error(t, err)
+}
+
+func TestServerConn_HandleCancel(t *testing.T) {
+	c, _ := newTestServerConn(t, nil)
+	c.query = &Query{ID: "q1"}
+
+	err := c.handleCancel()
+	require.NoError(t, err)
+	require.Nil(t, c.query, "handleCancel must drop the pending query")
+}
+
+func TestServerConn_HandlePing(t *testing.T) {
+	c, conn := newTestServerConn(t, nil)
+
+	err := c.handlePing()
+	require.NoError(t, err)
+	require.Positive(t, conn.written.Len(), "Pong must have been flushed")
+}